@@ -0,0 +1,204 @@
+package robo
+
+import (
+	"sort"
+	"strings"
+)
+
+// routeTable holds the routes registered with a Mux (and any of its With,
+// Group or Route descendants), along with the lazily-built index used to
+// dispatch requests in less than linear time.
+type routeTable struct {
+	routes []route
+
+	// index narrows an incoming request down to a small set of candidate
+	// routes before they're checked against the request for real. It's
+	// rebuilt the next time it's needed whenever routes have been added
+	// since it was last built.
+	index *indexNode
+	built bool
+}
+
+// add registers rt, invalidating the route index so it gets rebuilt
+// before the next request is dispatched.
+func (t *routeTable) add(rt route) {
+	rt.seq = len(t.routes)
+	t.routes = append(t.routes, rt)
+	t.built = false
+}
+
+// build compiles the route index from scratch.
+func (t *routeTable) build() {
+	root := newIndexNode()
+
+	for i := range t.routes {
+		segs, spans := compileIndexSegments(t.routes[i].pattern)
+		node := root.descend(segs)
+
+		if spans {
+			node.wildcard = append(node.wildcard, &t.routes[i])
+		} else {
+			node.routes = append(node.routes, &t.routes[i])
+		}
+	}
+
+	t.index = root
+	t.built = true
+}
+
+// candidates returns the routes that might match path, regardless of
+// method, in their original registration order, rebuilding the index
+// first if necessary. The caller is responsible for checking each
+// candidate against the request for real.
+func (t *routeTable) candidates(path string) []*route {
+	if !t.built {
+		t.build()
+	}
+
+	out := t.index.collect(pathSegments(path), nil)
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].seq < out[j].seq
+	})
+
+	return out
+}
+
+// indexNode is a node of the route index: a radix tree over patterns,
+// segmented by '/', with dedicated edges for a single dynamic ("{...}")
+// segment and a wildcard bucket for routes whose match can't be pinned
+// to a fixed number of segments from this point on.
+type indexNode struct {
+	children map[string]*indexNode
+	param    *indexNode
+	wildcard []*route
+
+	// routes whose pattern ends exactly at this depth
+	routes []*route
+}
+
+// newIndexNode initializes a new, empty indexNode.
+func newIndexNode() *indexNode {
+	return &indexNode{children: make(map[string]*indexNode)}
+}
+
+// descend walks the chain of child nodes described by segs, creating
+// nodes as needed, and returns the node reached at the end.
+func (n *indexNode) descend(segs []patternSegment) *indexNode {
+	if len(segs) == 0 {
+		return n
+	}
+
+	seg := segs[0]
+
+	if seg.dynamic {
+		if n.param == nil {
+			n.param = newIndexNode()
+		}
+		return n.param.descend(segs[1:])
+	}
+
+	child := n.children[seg.literal]
+	if child == nil {
+		child = newIndexNode()
+		n.children[seg.literal] = child
+	}
+	return child.descend(segs[1:])
+}
+
+// collect appends every route reachable under this node that might match
+// the remaining path segments to out, trying a literal child first, then
+// backtracking into the parameter branch, and finally the wildcard
+// branch (which, once reached, matches any number of remaining
+// segments).
+func (n *indexNode) collect(segments []string, out []*route) []*route {
+	out = append(out, n.wildcard...)
+
+	if len(segments) == 0 {
+		return append(out, n.routes...)
+	}
+
+	if child, ok := n.children[segments[0]]; ok {
+		out = child.collect(segments[1:], out)
+	}
+
+	if n.param != nil {
+		out = n.param.collect(segments[1:], out)
+	}
+
+	return out
+}
+
+// pathSegments splits a request path into '/'-delimited segments, the
+// unit the route index is keyed on.
+func pathSegments(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "/")
+}
+
+// patternSegment is one '/'-delimited unit of a compiled index plan:
+// either a literal, exact-matching piece, or an opaque placeholder
+// standing in for one or more parameter fragments (possibly glued to
+// surrounding literal text) that together consume exactly one path
+// segment.
+type patternSegment struct {
+	literal string
+	dynamic bool
+}
+
+// compileIndexSegments walks pattern fragment by fragment, the same way
+// compileMatcher does, and reduces it to the '/'-delimited segments the
+// route index can be keyed on. Fragments confined to a single path
+// segment -- literals, charset/plain parameters, and regexp parameters
+// whose source can't match '/' -- are folded into patternSegments in the
+// usual way. The first fragment that could consume more than one path
+// segment -- a wildcard ("*" or "**"), a regexp parameter whose source
+// explicitly matches '/', or a sub-pattern parameter -- ends the plan
+// early and reports spans = true, so the caller can fall back to an
+// always-a-candidate wildcard bucket at the node reached so far, rather
+// than trying to index something that doesn't decompose into a fixed
+// number of segments.
+func compileIndexSegments(pattern string) (segs []patternSegment, spans bool) {
+	var cur strings.Builder
+	var dynamic bool
+
+	for pattern != "" {
+		f, n, err := compileFragment(pattern)
+		if err != nil {
+			// pattern was already validated by compileMatcher when the
+			// route was registered.
+			panic(err)
+		}
+		pattern = pattern[n:]
+
+		switch f.t {
+		case literalFragment:
+			parts := strings.Split(f.s, "/")
+			cur.WriteString(parts[0])
+
+			for _, p := range parts[1:] {
+				segs = append(segs, patternSegment{literal: cur.String(), dynamic: dynamic})
+				cur.Reset()
+				dynamic = false
+				cur.WriteString(p)
+			}
+
+		case exclusiveFragment, inclusiveFragment:
+			dynamic = true
+
+		case regexpFragment:
+			if f.slash {
+				return segs, true
+			}
+			dynamic = true
+
+		default: // wildcardFragment, deepWildcardFragment, subPatternFragment
+			return segs, true
+		}
+	}
+
+	segs = append(segs, patternSegment{literal: cur.String(), dynamic: dynamic})
+	return segs, false
+}
@@ -2,6 +2,8 @@ package robo
 
 import (
 	"errors"
+	"regexp"
+	"strings"
 )
 
 var (
@@ -18,6 +20,11 @@ var (
 	errUnexpectedRBracket = errors.New("robo: unexpected ']'")
 	errMissingRBrace      = errors.New("robo: missing closing '}'")
 	errMissingRBracket    = errors.New("robo: missing closing ']'")
+	errEmptyRegexp        = errors.New("robo: empty parameter regexp")
+
+	errWildcardNotTerminal = errors.New("robo: '**' must end the pattern")
+	errEmptyVerb           = errors.New("robo: empty verb")
+	errVerbHasSlash        = errors.New("robo: verb includes '/'")
 )
 
 // The pathMatcher interface is used to match the paths of incoming requests.
@@ -27,7 +34,10 @@ type pathMatcher interface {
 	match(path string, buf []string) (bool, []string)
 }
 
-// compileMatcher compiles a pathMatcher from a pattern string.
+// compileMatcher compiles a pathMatcher from a pattern string. A trailing
+// ":verb" on the final segment (e.g. "/files/{path=**}:download", as used
+// by grpc-gateway) is split off and matched literally against the same
+// suffix of the input.
 func compileMatcher(pattern string) (pathMatcher, error) {
 	var fs []fragment
 
@@ -45,7 +55,69 @@ func compileMatcher(pattern string) (pathMatcher, error) {
 		pattern = pattern[n:]
 	}
 
-	return &fragmentMatcher{fs}, nil
+	fs, verb, err := splitVerb(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fragmentMatcher{fs, verb}, nil
+}
+
+// splitVerb looks for a trailing ":verb" on the pattern's final fragment,
+// which can only ever be a literal one (parameters and wildcards can't be
+// followed by raw text other than through a later literal fragment
+// anyway), and if found, strips it off that fragment's source (dropping
+// the fragment entirely if nothing but the verb is left) and returns it.
+func splitVerb(fs []fragment) ([]fragment, string, error) {
+	if len(fs) == 0 {
+		return fs, "", nil
+	}
+
+	last := &fs[len(fs)-1]
+	if last.t != literalFragment {
+		return fs, "", nil
+	}
+
+	i := indexUnescaped(last.s, ':')
+	if i < 0 {
+		return fs, "", nil
+	}
+
+	verb := last.s[i+1:]
+	if verb == "" {
+		return fs, "", errEmptyVerb
+	}
+	if strings.IndexByte(verb, '/') >= 0 {
+		return fs, "", errVerbHasSlash
+	}
+
+	last.s = last.s[:i]
+	last.n = len(last.s)
+
+	if last.s == "" {
+		fs = fs[:len(fs)-1]
+	}
+
+	return fs, verb, nil
+}
+
+// indexUnescaped returns the index of the first unescaped occurrence of c
+// in s, or -1 if there is none.
+func indexUnescaped(s string, c byte) int {
+	var e bool
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case e:
+			e = false
+		case s[i] == '\\':
+			e = true
+		case s[i] == c:
+			return i
+		}
+	}
+
+	return -1
 }
 
 // compileFragment compiles a fragment matcher from a prefix of a pattern
@@ -81,6 +153,12 @@ func compileLiteralFragment(pattern string) (fragment, int, error) {
 }
 
 func compileWildcardFragment(pattern string) (fragment, int, error) {
+	if strings.HasPrefix(pattern, "**") {
+		if pattern != "**" {
+			return fragment{}, 0, errWildcardNotTerminal
+		}
+		return fragment{t: deepWildcardFragment}, 2, nil
+	}
 	if pattern != "*" {
 		return fragment{}, 0, errIllegalWildcard
 	}
@@ -115,6 +193,43 @@ func compileParameterFragment(pattern string) (fragment, int, error) {
 			f = fragment{t: inclusiveFragment, s: pattern[1:i], r: chars}
 			return f, i + n + 1, nil
 
+		case c == ':':
+			if i == 1 {
+				return fragment{}, 0, errEmptyParameter
+			}
+
+			expr, n, err := compileRegexpBody(pattern[i+1:])
+			if err != nil {
+				return fragment{}, 0, err
+			}
+
+			f = fragment{
+				t:     regexpFragment,
+				s:     pattern[1:i],
+				re:    regexp.MustCompile("^(?:" + expr + ")"),
+				slash: strings.IndexByte(expr, '/') >= 0,
+			}
+			return f, i + 1 + n, nil
+
+		case c == '=':
+			name := pattern[1:i]
+			if name == "" {
+				return fragment{}, 0, errEmptyParameter
+			}
+
+			body, n, err := scanUntilRBrace(pattern[i+1:])
+			if err != nil {
+				return fragment{}, 0, err
+			}
+
+			sub, err := compileSubPattern(body)
+			if err != nil {
+				return fragment{}, 0, err
+			}
+
+			f = fragment{t: subPatternFragment, s: name, sub: sub}
+			return f, i + 1 + n, nil
+
 		case c == '}':
 			if i == 1 {
 				return fragment{}, 0, errEmptyParameter
@@ -138,6 +253,103 @@ func compileParameterFragment(pattern string) (fragment, int, error) {
 	return f, 0, errMissingRBrace
 }
 
+// scanUntilRBrace scans pattern for its first unescaped '}', returning
+// everything before it and how many bytes were consumed, including the
+// closing '}' itself.
+func scanUntilRBrace(pattern string) (string, int, error) {
+	var e bool
+
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case e:
+			e = false
+		case pattern[i] == '\\':
+			e = true
+		case pattern[i] == '}':
+			return pattern[:i], i + 1, nil
+		}
+	}
+
+	return "", 0, errMissingRBrace
+}
+
+// A subFragment describes one token of a sub-pattern, the right-hand side
+// of a parameter assignment like "{name=prefix/*/suffix}".
+type subFragment struct {
+	t   int
+	lit string
+}
+
+// subFragment types.
+const (
+	subLiteral = iota
+	subWildcard
+	subDeepWildcard
+)
+
+// compileSubPattern compiles the right-hand side of a "{name=...}"
+// parameter into a sequence of literal/wildcard tokens. Unlike top-level
+// patterns, a single '*' need not be the final token here (it only ever
+// matches a single path segment); a trailing "**" still has to end the
+// sub-pattern.
+func compileSubPattern(in string) ([]subFragment, error) {
+	var out []subFragment
+
+	for len(in) > 0 {
+		switch {
+		case strings.HasPrefix(in, "**"):
+			if in != "**" {
+				return nil, errWildcardNotTerminal
+			}
+			out = append(out, subFragment{t: subDeepWildcard})
+			in = in[2:]
+
+		case in[0] == '*':
+			out = append(out, subFragment{t: subWildcard})
+			in = in[1:]
+
+		default:
+			i := strings.IndexByte(in, '*')
+			if i < 0 {
+				i = len(in)
+			}
+			out = append(out, subFragment{t: subLiteral, lit: in[:i]})
+			in = in[i:]
+		}
+	}
+
+	return out, nil
+}
+
+// matchSubPattern matches a sub-pattern against a prefix of in, returning
+// the number of bytes consumed.
+func matchSubPattern(subs []subFragment, in string) (int, bool) {
+	var pos int
+
+	for _, s := range subs {
+		switch s.t {
+		case subLiteral:
+			if !strings.HasPrefix(in[pos:], s.lit) {
+				return 0, false
+			}
+			pos += len(s.lit)
+
+		case subWildcard:
+			rest := in[pos:]
+			if i := strings.IndexByte(rest, '/'); i >= 0 {
+				pos += i
+			} else {
+				pos += len(rest)
+			}
+
+		case subDeepWildcard:
+			pos = len(in)
+		}
+	}
+
+	return pos, true
+}
+
 func compileCharsetFragment(pattern string) ([]rune, int, error) {
 	var o []rune
 	var e bool
@@ -202,6 +414,44 @@ loop:
 	return nil, 0, errMissingRBracket
 }
 
+// compileRegexpBody scans the regexp source following a parameter's ':',
+// up to (and including) the '}' that closes the parameter, tracking '{'
+// and '}' nesting so a quantifier like "{3}" doesn't end the parameter
+// early, and '[' and ']' so a character class can contain an unescaped
+// '}'. It returns the regexp source and how many bytes were consumed,
+// including the closing '}'.
+func compileRegexpBody(pattern string) (string, int, error) {
+	var depth int
+	var class, e bool
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case e:
+			e = false
+		case c == '\\':
+			e = true
+		case class:
+			if c == ']' {
+				class = false
+			}
+		case c == '[':
+			class = true
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth == 0 {
+				if i == 0 {
+					return "", 0, errEmptyRegexp
+				}
+				return pattern[:i], i + 1, nil
+			}
+			depth--
+		}
+	}
+
+	return "", 0, errMissingRBrace
+}
+
 // simplifyCharset merges overlapping rune ranges in the input charset.
 func simplifyCharset(a []rune) []rune {
 	if len(a) == 0 {
@@ -249,12 +499,23 @@ func simplifyCharset(a []rune) []rune {
 }
 
 // fragmentMatcher is an implementation of the pathMatcher interface,
-// which matches input strings using precompiled fragments.
+// which matches input strings using precompiled fragments. If verb is
+// non-empty, the input must additionally end in ":" + verb, which is
+// stripped before the fragments are matched against what remains.
 type fragmentMatcher struct {
-	fs []fragment
+	fs   []fragment
+	verb string
 }
 
 func (f *fragmentMatcher) match(path string, buf []string) (bool, []string) {
+	if f.verb != "" {
+		suffix := ":" + f.verb
+		if !strings.HasSuffix(path, suffix) {
+			return false, nil
+		}
+		path = path[:len(path)-len(suffix)]
+	}
+
 	var n int
 
 	for _, f := range f.fs {
@@ -278,6 +539,17 @@ type fragment struct {
 	s string
 	n int
 	r []rune
+
+	// re and slash are only set for regexpFragment: re is the compiled,
+	// "^(?:...)"-anchored pattern, and slash reports whether its source
+	// explicitly matches '/', in which case matching isn't bounded to the
+	// current path segment.
+	re    *regexp.Regexp
+	slash bool
+
+	// sub is only set for subPatternFragment: the compiled tokens of the
+	// parameter's "{name=...}" right-hand side.
+	sub []subFragment
 }
 
 const (
@@ -285,6 +557,9 @@ const (
 	exclusiveFragment
 	inclusiveFragment
 	wildcardFragment
+	regexpFragment
+	deepWildcardFragment
+	subPatternFragment
 )
 
 func (f *fragment) matchPrefix(pattern string, buf []string) (int, []string) {
@@ -319,6 +594,31 @@ func (f *fragment) matchPrefix(pattern string, buf []string) (int, []string) {
 
 	case wildcardFragment:
 		return len(pattern), append(buf, "*", pattern)
+
+	case deepWildcardFragment:
+		return len(pattern), append(buf, "**", pattern)
+
+	case subPatternFragment:
+		n, ok := matchSubPattern(f.sub, pattern)
+		if !ok {
+			return -1, nil
+		}
+		return n, append(buf, f.s, pattern[:n])
+
+	case regexpFragment:
+		search := pattern
+		if !f.slash {
+			if i := strings.IndexByte(pattern, '/'); i >= 0 {
+				search = pattern[:i]
+			}
+		}
+
+		loc := f.re.FindStringIndex(search)
+		if loc == nil {
+			return -1, nil
+		}
+
+		return nonZero(loc[1]), append(buf, f.s, search[:loc[1]])
 	}
 
 	panic("unreachable")
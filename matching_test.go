@@ -66,9 +66,51 @@ var matcherTests = []struct {
 		{"/foo", false, nil},
 		{"/123", false, nil},
 	}},
+	{"/users/{id:[0-9]+}", nil, []matcherCheck{
+		{"/users/", false, nil},
+		{"/users/42", true, []string{"id", "42"}},
+		{"/users/foo", false, nil},
+		{"/users/42/extra", false, nil},
+	}},
+	{"/{name:[a-z]{3}}", nil, []matcherCheck{
+		{"/ab", false, nil},
+		{"/abc", true, []string{"name", "abc"}},
+		{"/abcd", false, nil},
+	}},
+	{"/files/{path:[^/]+}", nil, []matcherCheck{
+		{"/files/", false, nil},
+		{"/files/a", true, []string{"path", "a"}},
+		{"/files/a/b", false, nil},
+	}},
+	{"/files/{path:.+/.+}", nil, []matcherCheck{
+		{"/files/a", false, nil},
+		{"/files/a/b", true, []string{"path", "a/b"}},
+		{"/files/a/b/c", true, []string{"path", "a/b/c"}},
+	}},
+	{"/**", nil, []matcherCheck{
+		{"/", true, []string{"**", ""}},
+		{"/foo/bar", true, []string{"**", "foo/bar"}},
+	}},
+	{"/files/{path=**}", nil, []matcherCheck{
+		{"/files/", true, []string{"path", ""}},
+		{"/files/a/b/c", true, []string{"path", "a/b/c"}},
+	}},
+	{"/files/{path=*/*}", nil, []matcherCheck{
+		{"/files/a", false, nil},
+		{"/files/a/b", true, []string{"path", "a/b"}},
+		{"/files/a/b/c", false, nil},
+	}},
+	{"/files/{path=**}:download", nil, []matcherCheck{
+		{"/files/a/b:download", true, []string{"path", "a/b"}},
+		{"/files/a/b", false, nil},
+	}},
 
 	{"", errEmptyPattern, nil},
 	{"/*/foo", errIllegalWildcard, nil},
+	{"/**/foo", errWildcardNotTerminal, nil},
+	{"/files/{path=**/foo}", errWildcardNotTerminal, nil},
+	{"/files/{path=*}:", errEmptyVerb, nil},
+	{"/files/{path=*}:a/b", errVerbHasSlash, nil},
 	{"/{foo", errMissingRBrace, nil},
 	{"/{foo[]}", errEmptyCharset, nil},
 	{"/{foo[}", errMissingRBracket, nil},
@@ -77,6 +119,8 @@ var matcherTests = []struct {
 	{"/{foo[abc[]}", errUnexpectedLBracket, nil},
 	{"/{foo[z-a]}", errImpossibleRange, nil},
 	{"/{foo[a-b-c]}", errUnexpectedHyphen, nil},
+	{"/{foo:}", errEmptyRegexp, nil},
+	{"/{foo:[0-9]+", errMissingRBrace, nil},
 }
 
 func TestMatcher(t *testing.T) {
@@ -1,8 +1,12 @@
 package robo
 
 import (
+	"context"
 	"net/http"
 	"net/url"
+	"path"
+	"sort"
+	"strings"
 )
 
 // Objects implementing the Handler interface are capable of serving
@@ -35,53 +39,154 @@ type ResponseWriter interface {
 	http.ResponseWriter
 }
 
-// The Request type extends an http.Request instance with additional
-// functionality.
-type Request struct {
-	*http.Request
-
-	// parsed querystring values
-	query url.Values
+// Mux is a HTTP router. It multiplexes incoming requests to different
+// handlers based on user-provided rules on methods and paths.
+//
+// The zero value for a Mux is a Mux without any registered handlers,
+// ready to use.
+type Mux struct {
+	// table holds the shared route table. It's a pointer so that inline
+	// Mux values derived from this one via With, Group or Route keep
+	// registering into the same table as their parent.
+	table *routeTable
+
+	// prefix is prepended to every pattern registered through this Mux. It
+	// is only ever non-empty on the inline Mux instances created by Route.
+	prefix string
+
+	// mw holds the middleware stack applied to routes registered through
+	// this Mux, ahead of their own handlers.
+	mw []Handler
+
+	// NotFoundHandler, if set, overrides the default response sent when no
+	// route matches a request's path.
+	NotFoundHandler http.Handler
+
+	// MethodNotAllowedHandler, if set, overrides the default response sent
+	// when a route matches a request's path but not its method. The Allow
+	// header is already set to the accepted methods by the time it runs.
+	MethodNotAllowedHandler http.Handler
+
+	// PathOptions controls how an incoming request's path is normalized
+	// before being matched against registered routes.
+	PathOptions PathOptions
+}
 
-	// named URL parameters for this request and route
-	params map[string]string
+// PathOptions controls how ServeRoboHTTP normalizes a request's path
+// before matching it against a Mux's routes. The zero value disables
+// every option, matching robo's original behavior.
+type PathOptions struct {
+	// UseRawPath, if set, matches against the request URL's raw,
+	// still-escaped path (http.Request.URL.RawPath) instead of its
+	// decoded one, so that an escaped '/' (a literal "%2F") inside a
+	// segment isn't mistaken for a path separator. Captured parameters
+	// are unescaped back to their decoded form regardless.
+	UseRawPath bool
+
+	// RedirectTrailingSlash, if set, redirects requests whose path only
+	// matches a registered route after adding or stripping a trailing
+	// slash (e.g. "/foo/" when only "/foo" is registered) to that form.
+	RedirectTrailingSlash bool
+
+	// RedirectFixedPath, if set, redirects requests whose path contains
+	// "." or ".." segments, or duplicate slashes, to the cleaned
+	// equivalent, provided the cleaned path matches a route.
+	RedirectFixedPath bool
+
+	// StrictSlash, if set, makes a route match regardless of a trailing
+	// slash: a route registered as "/foo" also matches "/foo/", and vice
+	// versa, without a redirect. It takes precedence over
+	// RedirectTrailingSlash, since it never leaves a slash mismatch for
+	// that option to redirect.
+	StrictSlash bool
+}
 
-	// reference to the queue
-	queue *queue
+// NewMux returns a new Mux instance.
+func NewMux() *Mux {
+	return new(Mux)
 }
 
-// Next yields execution to the next matching handler, if there is one,
-// blocking until said handler has returned.
-func (r *Request) Next(w ResponseWriter) {
-	r.queue.serveNext(w, r.Request)
+// Use appends middleware to the Mux's middleware stack. It only affects
+// routes registered (via Any, or one of its shorthands) after the call.
+// Middleware can be given as a plain handler, in any of the shapes add
+// accepts (in which case it's expected to call Request.Next itself to
+// continue the chain), or as a middleware constructor: either robo's own
+// func(Handler) Handler, or a standard func(http.Handler) http.Handler,
+// which is adapted automatically so that ecosystem middleware (CORS,
+// logging, gzip, ...) can be dropped in without modification.
+func (m *Mux) Use(mw ...interface{}) {
+	for _, fn := range mw {
+		m.mw = append(m.mw, adaptMiddleware(fn))
+	}
 }
 
-// Query returns the value of a particular querystring parameter, after
-// lazily parsing the raw querystring.
-func (r *Request) Query(name string) string {
-	if r.query == nil {
-		r.query = r.URL.Query()
+// With returns an inline Mux sharing m's route table, which applies the
+// given middleware (see Use) in addition to m's own only to routes
+// registered through the returned Mux.
+func (m *Mux) With(mw ...interface{}) *Mux {
+	if m.table == nil {
+		m.table = &routeTable{}
 	}
-	return r.query.Get(name)
+
+	sub := *m
+	sub.mw = append([]Handler{}, m.mw...)
+
+	for _, fn := range mw {
+		sub.mw = append(sub.mw, adaptMiddleware(fn))
+	}
+
+	return &sub
 }
 
-// Param returns the value of a named URL parameter.
-func (r *Request) Param(name string) string {
-	return r.params[name]
+// Group runs fn with an inline Mux (see With) so that any middleware it
+// adds via Use is scoped to the routes fn registers, without affecting m.
+func (m *Mux) Group(fn func(*Mux)) {
+	fn(m.With())
 }
 
-// Mux is a HTTP router. It multiplexes incoming requests to different
-// handlers based on user-provided rules on methods and paths.
-//
-// The zero value for a Mux is a Mux without any registered handlers,
-// ready to use.
-type Mux struct {
-	routes []route
+// Route runs fn with an inline Mux (see With) whose patterns are mounted
+// under prefix, a literal path prefix (it is not matched as a pattern).
+func (m *Mux) Route(prefix string, fn func(*Mux)) {
+	sub := m.With()
+	sub.prefix = m.prefix + prefix
+	fn(sub)
 }
 
-// NewMux returns a new Mux instance.
-func NewMux() *Mux {
-	return new(Mux)
+// adaptMiddleware converts fn into a Handler that can be spliced into a
+// route's handler chain ahead of its own handlers. fn may be given in any
+// of the shapes add accepts (a plain handler, which is used as-is and is
+// expected to call Request.Next itself to continue the chain), or as a
+// middleware constructor: either robo's own func(Handler) Handler, or a
+// standard func(http.Handler) http.Handler, both of which are wrapped so
+// that invoking the "next" handler they're given resumes the chain via
+// Request.Next.
+func adaptMiddleware(fn interface{}) Handler {
+	switch mw := fn.(type) {
+	case Handler:
+		return mw
+	case func(w ResponseWriter, r *Request):
+		return HandlerFunc(mw)
+	case http.Handler:
+		return httpHandler{mw}
+	case func(w http.ResponseWriter, r *http.Request):
+		return httpHandler{http.HandlerFunc(mw)}
+	case func(Handler) Handler:
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			next := HandlerFunc(func(w ResponseWriter, r *Request) {
+				r.Next(w)
+			})
+			mw(next).ServeRoboHTTP(w, r)
+		})
+	case func(http.Handler) http.Handler:
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, hr *http.Request) {
+				r.Next(w)
+			})
+			mw(next).ServeHTTP(w, r.Request)
+		})
+	default:
+		panic("robo: not a valid middleware")
+	}
 }
 
 // Any registers a new set of handlers listening to all requests for
@@ -135,12 +240,22 @@ func (m *Mux) Options(pattern string, handlers ...interface{}) {
 // add registers a set of handlers for the given HTTP method ("" matching
 // any method) and URL pattern.
 func (m *Mux) add(method, pattern string, handlers ...interface{}) {
+	if m.table == nil {
+		m.table = &routeTable{}
+	}
+
+	m.table.add(newRoute(method, m.prefix+pattern, m.handlerChain(handlers)))
+}
+
+// handlerChain resolves handlers (in any of the shapes add and Handle
+// accept) into a single chain, with m's middleware stack spliced in front.
+func (m *Mux) handlerChain(handlers []interface{}) []Handler {
 	if len(handlers) == 0 {
 		panic("no handlers provided")
 	}
 
-	// validate the provided set of handlers
-	clean := make([]Handler, 0, len(handlers))
+	clean := make([]Handler, 0, len(m.mw)+len(handlers))
+	clean = append(clean, m.mw...)
 
 	for _, h := range handlers {
 		switch h := h.(type) {
@@ -157,7 +272,7 @@ func (m *Mux) add(method, pattern string, handlers ...interface{}) {
 		}
 	}
 
-	m.routes = append(m.routes, newRoute(method, pattern, clean))
+	return clean
 }
 
 // newRoute initializes a new route.
@@ -167,64 +282,382 @@ func newRoute(method, pattern string, handlers []Handler) route {
 		panic(err)
 	}
 
-	return route{method, matcher, handlers}
+	return route{method: method, pattern: pattern, matcher: matcher, handlers: handlers}
+}
+
+// Build eagerly compiles the route index used to dispatch requests. It
+// only ever needs to be called explicitly to keep it off the critical
+// path of the first request served; ServeRoboHTTP calls it automatically
+// otherwise, and again whenever routes are registered after it last ran.
+func (m *Mux) Build() {
+	if m.table != nil {
+		m.table.build()
+	}
 }
 
 // ServeRoboHTTP dispatches the request to matching routes registered with
 // the Mux instance.
+//
+// If no route matches the request's path at all, NotFoundHandler (or a
+// default 404 response) is used. If the path matches but the method
+// doesn't, MethodNotAllowedHandler (or a default 405 response) is used
+// instead, with the Allow header set to the accepted methods.
+//
+// A HEAD request is dispatched to a matching GET route when no route was
+// registered for HEAD explicitly. An OPTIONS request is answered
+// automatically with the Allow header, unless a route was registered for
+// OPTIONS explicitly.
+//
+// PathOptions, if set, may normalize the path used for matching (see its
+// fields) or redirect the request to a canonical form before any of the
+// above happens.
 func (m *Mux) ServeRoboHTTP(w ResponseWriter, r *Request) {
-	q := queue{nil, nil, m.routes}
+	path := m.matchPath(r.Request)
+
+	if m.PathOptions.StrictSlash && !m.hasRoute(r.Request, path) {
+		if alt := toggleTrailingSlash(path); alt != path && m.hasRoute(r.Request, alt) {
+			path = alt
+		}
+	}
+
+	if !m.hasRoute(r.Request, path) {
+		if m.PathOptions.RedirectTrailingSlash {
+			if alt := toggleTrailingSlash(path); alt != path && m.hasRoute(r.Request, alt) {
+				m.redirectTo(w, r.Request, alt)
+				return
+			}
+		}
+
+		if m.PathOptions.RedirectFixedPath {
+			if alt := cleanPath(path); alt != path && m.hasRoute(r.Request, alt) {
+				m.redirectTo(w, r.Request, alt)
+				return
+			}
+		}
+	}
+
+	candidates := m.checkedCandidates(r.Request, path)
+
+	if r.Method == "OPTIONS" {
+		routes := matching(candidates, "OPTIONS")
+		if len(routes) > 0 {
+			q := queue{nil, nil, routes, path, m.PathOptions.UseRawPath, m.notFoundHandler()}
+			q.serveNext(w, r.Request)
+			return
+		}
+
+		if allow := allowedMethods(candidates); len(allow) > 0 {
+			m.serveThroughMiddleware(w, r, path, http.HandlerFunc(func(w http.ResponseWriter, hr *http.Request) {
+				w.Header().Set("Allow", strings.Join(allow, ", "))
+				w.WriteHeader(http.StatusNoContent)
+			}))
+		} else {
+			m.serveThroughMiddleware(w, r, path, m.notFoundHandler())
+		}
+		return
+	}
+
+	routes := matching(candidates, r.Method)
+	if len(routes) == 0 && r.Method == "HEAD" {
+		routes = matching(candidates, "GET")
+	}
+
+	if len(routes) == 0 {
+		allow := allowedMethods(candidates)
+		if len(allow) == 0 {
+			m.serveThroughMiddleware(w, r, path, m.notFoundHandler())
+			return
+		}
+
+		m.serveThroughMiddleware(w, r, path, http.HandlerFunc(func(w http.ResponseWriter, hr *http.Request) {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			m.methodNotAllowedHandler().ServeHTTP(w, hr)
+		}))
+		return
+	}
+
+	q := queue{nil, nil, routes, path, m.PathOptions.UseRawPath, m.notFoundHandler()}
+	q.serveNext(w, r.Request)
+}
+
+// serveThroughMiddleware runs target through m's middleware stack, the
+// same way a matched route's handler chain does, so that mux-level
+// middleware (logging, recovery, ...) observes 404/405/automatic-OPTIONS
+// responses too, not just requests a route actually handled.
+func (m *Mux) serveThroughMiddleware(w ResponseWriter, r *Request, path string, target http.Handler) {
+	q := queue{m.mw, nil, nil, path, m.PathOptions.UseRawPath, target}
 	q.serveNext(w, r.Request)
 }
 
+// matchPath returns the path hr is matched against, honoring
+// PathOptions.UseRawPath.
+func (m *Mux) matchPath(hr *http.Request) string {
+	if m.PathOptions.UseRawPath && hr.URL.RawPath != "" {
+		return hr.URL.RawPath
+	}
+	return hr.URL.Path
+}
+
+// hasRoute reports whether any registered route actually matches path,
+// regardless of method.
+func (m *Mux) hasRoute(hr *http.Request, path string) bool {
+	return len(m.checkedCandidates(hr, path)) > 0
+}
+
+// checkedCandidates returns the routes among m.table's candidates for path
+// that actually match it, confirmed via route.check. routeTable.candidates
+// alone only narrows candidates down by path shape, and can include routes
+// whose pattern (or a regex-constrained parameter within it) doesn't
+// really match path, or whose host/scheme/header predicates (see
+// Mux.Handle) reject hr outright -- required for both a correct Allow
+// header and an accurate 404-vs-405 (or 204 OPTIONS) decision.
+func (m *Mux) checkedCandidates(hr *http.Request, path string) []*route {
+	if m.table == nil {
+		return nil
+	}
+
+	candidates := m.table.candidates(path)
+	out := make([]*route, 0, len(candidates))
+
+	for _, rt := range candidates {
+		if ok, _ := rt.check(hr, path); ok {
+			out = append(out, rt)
+		}
+	}
+
+	return out
+}
+
+// toggleTrailingSlash returns path with its trailing slash added or
+// stripped, whichever applies.
+func toggleTrailingSlash(p string) string {
+	if p == "" {
+		return p
+	}
+
+	if strings.HasSuffix(p, "/") {
+		if len(p) > 1 {
+			return p[:len(p)-1]
+		}
+		return p
+	}
+
+	return p + "/"
+}
+
+// cleanPath collapses duplicate slashes and resolves "." and ".." segments
+// in p, without ever escaping the root. A trailing slash is preserved.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	trailing := len(p) > 1 && p[len(p)-1] == '/'
+	clean := path.Clean(p)
+
+	if trailing && clean[len(clean)-1] != '/' {
+		clean += "/"
+	}
+
+	return clean
+}
+
+// redirectTo redirects hr to the same URL with its path replaced by path.
+func (m *Mux) redirectTo(w http.ResponseWriter, hr *http.Request, path string) {
+	code := http.StatusMovedPermanently
+	if hr.Method != "GET" {
+		code = http.StatusPermanentRedirect
+	}
+
+	u := *hr.URL
+	u.Path = path
+	u.RawPath = ""
+
+	http.Redirect(w, hr, u.String(), code)
+}
+
 // ServeHTTP dispatches the request to matching routes registered with
 // the Mux instance.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	m.ServeRoboHTTP(w, &Request{Request: r})
 }
 
+// notFoundHandler returns NotFoundHandler, or a default 404 responder if
+// it's unset.
+func (m *Mux) notFoundHandler() http.Handler {
+	if m.NotFoundHandler != nil {
+		return m.NotFoundHandler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not found.\n", http.StatusNotFound)
+	})
+}
+
+// methodNotAllowedHandler returns MethodNotAllowedHandler, or a default
+// 405 responder if it's unset.
+func (m *Mux) methodNotAllowedHandler() http.Handler {
+	if m.MethodNotAllowedHandler != nil {
+		return m.MethodNotAllowedHandler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Method not allowed.\n", http.StatusMethodNotAllowed)
+	})
+}
+
+// matching returns the routes among candidates that accept method.
+func matching(candidates []*route, method string) []*route {
+	out := make([]*route, 0, len(candidates))
+	for _, rt := range candidates {
+		if routeAllowsMethod(rt, method) {
+			out = append(out, rt)
+		}
+	}
+	return out
+}
+
+// routeAllowsMethod reports whether rt accepts method. A Methods
+// restriction attached through Mux.Handle takes precedence; otherwise rt's
+// own method field is used, with "" meaning "any method".
+func routeAllowsMethod(rt *route, method string) bool {
+	if rt.pred != nil && rt.pred.methods != nil {
+		return rt.pred.methods[method]
+	}
+	return rt.method == "" || rt.method == method
+}
+
+// allowedMethods returns the sorted, de-duplicated set of HTTP methods
+// accepted by candidates, for use in an Allow header. HEAD is implied by
+// GET, and OPTIONS is implied by any non-empty result, since both are
+// handled automatically by ServeRoboHTTP.
+func allowedMethods(candidates []*route) []string {
+	seen := make(map[string]bool)
+
+	for _, rt := range candidates {
+		switch {
+		case rt.pred != nil && rt.pred.methods != nil:
+			for method := range rt.pred.methods {
+				seen[method] = true
+			}
+		case rt.method != "":
+			seen[rt.method] = true
+		}
+	}
+
+	if seen["GET"] {
+		seen["HEAD"] = true
+	}
+	if len(seen) > 0 {
+		seen["OPTIONS"] = true
+	}
+
+	out := make([]string, 0, len(seen))
+	for method := range seen {
+		out = append(out, method)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
 // The route type describes a registered route.
 type route struct {
 	method   string
+	pattern  string
 	matcher  pathMatcher
 	handlers []Handler
+
+	// pred, if set (via Mux.Handle), holds additional method/host/scheme/
+	// header predicates the request must also satisfy.
+	pred *predicates
+
+	// seq is the route's position in its Mux's registration order, used to
+	// restore that order after the route index returns candidates from
+	// several of its branches.
+	seq int
 }
 
 var emptyParams = make(map[string]string)
 
-// check tests whether the route matches a provided method and path. The
-// parameter map will always be non-nil when the first is true.
-func (r *route) check(method, path string) (bool, map[string]string) {
-	if method != r.method && r.method != "" {
-		return false, nil
-	}
-
+// check tests whether the route matches a provided path and, if it has
+// predicates attached (see Mux.Handle), the rest of the request. Method
+// matching itself happens later, in matching/allowedMethods, since a HEAD
+// request may need to be checked against a route registered for GET. The
+// parameter map will always be non-nil when the first return value is
+// true.
+func (r *route) check(hr *http.Request, path string) (bool, map[string]string) {
 	ok, list := r.matcher.match(path, nil)
 	if !ok {
 		return false, nil
 	}
 
+	var hostParams []string
+	if r.pred != nil {
+		ok, hostParams = r.pred.match(hr)
+		if !ok {
+			return false, nil
+		}
+	}
+
 	// don't build the actual parameter map unless we have to
-	if len(list) == 0 {
+	if len(list) == 0 && len(hostParams) == 0 {
 		return true, emptyParams
 	}
 
-	params := make(map[string]string)
+	params := make(map[string]string, (len(list)+len(hostParams))/2)
 	for i := 0; i < len(list); i += 2 {
 		params[list[i]] = list[i+1]
 	}
+	for i := 0; i < len(hostParams); i += 2 {
+		params[hostParams[i]] = hostParams[i+1]
+	}
 
 	return true, params
 }
 
+// decodeParams returns params with each value unescaped, for use when
+// PathOptions.UseRawPath left captured values in their raw, escaped form.
+func decodeParams(params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return params
+	}
+
+	out := make(map[string]string, len(params))
+	for name, value := range params {
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+		out[name] = value
+	}
+
+	return out
+}
+
 // The queue type holds the routing state of an incoming request.
 type queue struct {
 	// remaining handlers, and parameter map, for the current route
 	handlers []Handler
 	params   map[string]string
 
-	// remaining routes to be tested
-	routes []route
+	// remaining routes to be tested, already narrowed down to candidates
+	// for the request's method and path by the route index
+	routes []*route
+
+	// path is the (already normalized, per Mux.PathOptions) path routes
+	// are checked against, which may differ from the request's own
+	// r.URL.Path.
+	path string
+
+	// decodeParams, if set, makes serveNext unescape captured parameter
+	// values, since path is then in its raw, still-escaped form.
+	decodeParams bool
+
+	// notFound is served once every route's handlers have run their
+	// course via Next without one of them responding
+	notFound http.Handler
 }
 
 // ServeNext attempts to serve an HTTP request using the next matching
@@ -245,11 +678,20 @@ func (q *queue) serveNext(w ResponseWriter, hr *http.Request) {
 		q.routes = q.routes[1:]
 
 		// does this route match the request at hand?
-		ok, params := r.check(hr.Method, hr.URL.Path)
+		ok, params := r.check(hr, q.path)
 		if !ok {
 			continue
 		}
 
+		if q.decodeParams {
+			params = decodeParams(params)
+		}
+
+		// stash the captured parameters in the request's context too, so
+		// they're reachable as ParamsFromContext from handlers that only
+		// ever see a plain *http.Request
+		hr = hr.WithContext(context.WithValue(hr.Context(), paramsContextKey{}, params))
+
 		q.handlers = r.handlers[1:]
 		q.params = params
 
@@ -258,6 +700,6 @@ func (q *queue) serveNext(w ResponseWriter, hr *http.Request) {
 		return
 	}
 
-	// when we run out of routes, send a 404 message
-	http.Error(w, "Not found.\n", 404)
+	// when we run out of routes, fall back to the not-found response
+	q.notFound.ServeHTTP(w, hr)
 }
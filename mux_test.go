@@ -0,0 +1,393 @@
+package robo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMuxMiddleware(t *testing.T) {
+	var trail []string
+
+	mark := func(name string) func(Handler) Handler {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(w ResponseWriter, r *Request) {
+				trail = append(trail, name+":before")
+				next.ServeRoboHTTP(w, r)
+				trail = append(trail, name+":after")
+			})
+		}
+	}
+
+	markHTTP := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trail = append(trail, name+":before")
+				next.ServeHTTP(w, r)
+				trail = append(trail, name+":after")
+			})
+		}
+	}
+
+	mux := NewMux()
+	mux.Use(mark("outer"))
+
+	mux.Route("/api", func(sub *Mux) {
+		sub.Use(markHTTP("inner"))
+
+		sub.Get("/ping", func(w ResponseWriter, r *Request) {
+			trail = append(trail, "handler")
+			w.WriteHeader(200)
+		})
+	})
+
+	mux.Get("/plain", func(w ResponseWriter, r *Request) {
+		trail = append(trail, "handler")
+		w.WriteHeader(200)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/api/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	want := []string{"outer:before", "inner:before", "handler", "inner:after", "outer:after"}
+	if !equalStrings(trail, want) {
+		t.Fatalf("trail = %v, want %v", trail, want)
+	}
+
+	trail = nil
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "http://example.com/plain", nil)
+	mux.ServeHTTP(w, r)
+
+	want = []string{"outer:before", "handler", "outer:after"}
+	if !equalStrings(trail, want) {
+		t.Fatalf("trail = %v, want %v", trail, want)
+	}
+}
+
+func TestMuxDispatchOrder(t *testing.T) {
+	var trail []string
+
+	mux := NewMux()
+
+	mux.Any("*", func(w ResponseWriter, r *Request) {
+		trail = append(trail, "catch-all")
+		r.Next(w)
+	})
+
+	mux.Get("/users/{id}", func(w ResponseWriter, r *Request) {
+		trail = append(trail, "users:"+r.Param("id"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/users/42", nil)
+	mux.ServeHTTP(w, r)
+
+	want := []string{"catch-all", "users:42"}
+	if !equalStrings(trail, want) {
+		t.Fatalf("trail = %v, want %v", trail, want)
+	}
+}
+
+func TestRequestStore(t *testing.T) {
+	mux := NewMux()
+
+	mux.Use(func(w ResponseWriter, r *Request) {
+		r.Set("user", "alice")
+		r.Next(w)
+	})
+
+	mux.Get("/ping", func(w ResponseWriter, r *Request) {
+		fmt.Fprint(w, r.Get("user"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if body := w.Body.String(); body != "alice" {
+		t.Fatalf("body = %q, want %q", body, "alice")
+	}
+}
+
+func TestParamsFromContext(t *testing.T) {
+	mux := NewMux()
+
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, ParamsFromContext(r.Context())["id"])
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	mux.Get("/users/{id}", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/users/42", nil)
+	mux.ServeHTTP(w, r)
+
+	if body := w.Body.String(); body != "42" {
+		t.Fatalf("body = %q, want %q", body, "42")
+	}
+}
+
+func TestMuxMethodNotAllowed(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/users/{id}", func(w ResponseWriter, r *Request) {})
+	mux.Post("/users/{id}", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("DELETE", "http://example.com/users/42", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 405 {
+		t.Fatalf("code = %d, want 405", w.Code)
+	}
+
+	want := "GET, HEAD, OPTIONS, POST"
+	if allow := w.Header().Get("Allow"); allow != want {
+		t.Fatalf("Allow = %q, want %q", allow, want)
+	}
+}
+
+func TestMuxMethodNotAllowedRespectsParamConstraints(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/users/{id:[0-9]+}", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "http://example.com/users/abc", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Fatalf("code = %d, want 404", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("OPTIONS", "http://example.com/users/abc", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Fatalf("code = %d, want 404", w.Code)
+	}
+}
+
+func TestMuxMiddlewareRunsOnUnmatchedPath(t *testing.T) {
+	var trail []string
+
+	mux := NewMux()
+	mux.Use(func(w ResponseWriter, r *Request) {
+		trail = append(trail, "before")
+		r.Next(w)
+		trail = append(trail, "after")
+	})
+	mux.Get("/users/{id}", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/missing", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Fatalf("code = %d, want 404", w.Code)
+	}
+
+	want := []string{"before", "after"}
+	if !equalStrings(trail, want) {
+		t.Fatalf("trail = %v, want %v", trail, want)
+	}
+
+	trail = nil
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "http://example.com/users/42", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 405 {
+		t.Fatalf("code = %d, want 405", w.Code)
+	}
+	if !equalStrings(trail, want) {
+		t.Fatalf("trail = %v, want %v", trail, want)
+	}
+}
+
+func TestMuxDispatchSlashSpanningParam(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/files/{path:.+/.+}", func(w ResponseWriter, r *Request) {
+		fmt.Fprint(w, r.Param("path"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/files/a/b", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("code = %d, want 200", w.Code)
+	}
+	if body := w.Body.String(); body != "a/b" {
+		t.Fatalf("body = %q, want %q", body, "a/b")
+	}
+}
+
+func TestMuxNotFound(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/users/{id}", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/other", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Fatalf("code = %d, want 404", w.Code)
+	}
+}
+
+func TestMuxAutoHead(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/ping", func(w ResponseWriter, r *Request) {
+		w.Write([]byte("pong"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("HEAD", "http://example.com/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("code = %d, want 200", w.Code)
+	}
+}
+
+func TestMuxAutoOptions(t *testing.T) {
+	mux := NewMux()
+	mux.Get("/ping", func(w ResponseWriter, r *Request) {})
+	mux.Post("/ping", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("OPTIONS", "http://example.com/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 204 {
+		t.Fatalf("code = %d, want 204", w.Code)
+	}
+
+	want := "GET, HEAD, OPTIONS, POST"
+	if allow := w.Header().Get("Allow"); allow != want {
+		t.Fatalf("Allow = %q, want %q", allow, want)
+	}
+}
+
+func TestMuxCustomNotFoundHandler(t *testing.T) {
+	mux := NewMux()
+	mux.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", 404)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/missing", nil)
+	mux.ServeHTTP(w, r)
+
+	if body := w.Body.String(); body != "nope\n" {
+		t.Fatalf("body = %q, want %q", body, "nope\n")
+	}
+}
+
+func TestMuxRedirectTrailingSlash(t *testing.T) {
+	mux := NewMux()
+	mux.PathOptions.RedirectTrailingSlash = true
+	mux.Get("/foo", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/foo/", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 301 {
+		t.Fatalf("code = %d, want 301", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "http://example.com/foo" {
+		t.Fatalf("Location = %q, want %q", loc, "http://example.com/foo")
+	}
+}
+
+func TestMuxRedirectFixedPath(t *testing.T) {
+	mux := NewMux()
+	mux.PathOptions.RedirectFixedPath = true
+	mux.Get("/foo/bar", func(w ResponseWriter, r *Request) {})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/foo//baz/../bar", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 301 {
+		t.Fatalf("code = %d, want 301", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "http://example.com/foo/bar" {
+		t.Fatalf("Location = %q, want %q", loc, "http://example.com/foo/bar")
+	}
+}
+
+func TestMuxStrictSlash(t *testing.T) {
+	mux := NewMux()
+	mux.PathOptions.StrictSlash = true
+	mux.Get("/foo", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(200)
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/foo/", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("code = %d, want 200", w.Code)
+	}
+}
+
+func TestMuxUseRawPath(t *testing.T) {
+	mux := NewMux()
+	mux.PathOptions.UseRawPath = true
+	mux.Get("/files/{name}", func(w ResponseWriter, r *Request) {
+		fmt.Fprint(w, r.Param("name"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/files/a%2Fb", nil)
+	mux.ServeHTTP(w, r)
+
+	if body := w.Body.String(); body != "a/b" {
+		t.Fatalf("body = %q, want %q", body, "a/b")
+	}
+}
+
+func BenchmarkMuxDispatch(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("routes=%d", n), func(b *testing.B) {
+			mux := NewMux()
+
+			for i := 0; i < n; i++ {
+				mux.Get(fmt.Sprintf("/resource%d/{id}", i), func(w ResponseWriter, r *Request) {})
+			}
+
+			mux.Build()
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", fmt.Sprintf("http://example.com/resource%d/42", n-1), nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				mux.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package robo
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 )
@@ -16,10 +17,6 @@ type Request struct {
 	// named URL parameters, specific to the route
 	params map[string]string
 
-	// pointer to the request-local data map, which is stored in the
-	// queue and shared between all routes
-	store **map[string]interface{}
-
 	// reference to the request's queue, used by the Next method
 	queue *queue
 }
@@ -44,20 +41,41 @@ func (r *Request) Param(name string) string {
 	return r.params[name]
 }
 
-// Get returns a value stored in the request's data store (or nil if
-// it hasn't been defined yet).
+// WithContext replaces the request's context.Context with ctx, the same
+// way http.Request.WithContext does, and updates the underlying
+// http.Request in place so that Next passes the change on to downstream
+// handlers, along with any cancellation ctx carries.
+func (r *Request) WithContext(ctx context.Context) {
+	r.Request = r.Request.WithContext(ctx)
+}
+
+// storeKey is the context key type used by Get and Set. It's unexported
+// so that values stashed this way can't collide with, or be read by,
+// unrelated context.WithValue calls.
+type storeKey string
+
+// Get returns a value stored in the request's context (or nil if it
+// hasn't been defined yet).
 func (r *Request) Get(key string) interface{} {
-	if *r.store == nil {
-		return nil
-	}
-	return (**r.store)[key]
+	return r.Context().Value(storeKey(key))
 }
 
-// Set stores a value in the request's data store.
+// Set stores a value under key in the request's context, the same way
+// WithContext does.
 func (r *Request) Set(key string, value interface{}) {
-	if *r.store == nil {
-		m := make(map[string]interface{})
-		*r.store = &m
-	}
-	(**r.store)[key] = value
+	r.WithContext(context.WithValue(r.Context(), storeKey(key), value))
+}
+
+// paramsContextKey is the context.Context key URL parameters are stored
+// under, so that they're reachable from a plain *http.Request -- for
+// instance from inside third-party middleware adapted through
+// httpHandler, which never sees a *Request.
+type paramsContextKey struct{}
+
+// ParamsFromContext returns the URL parameters captured by the route
+// that matched the request ctx belongs to, or nil if there weren't any,
+// or ctx didn't originate from a Mux in the first place.
+func ParamsFromContext(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(paramsContextKey{}).(map[string]string)
+	return params
 }
@@ -0,0 +1,144 @@
+package robo
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// predicates holds the extra request matchers attached to a route through
+// Mux.Handle, beyond its path pattern.
+type predicates struct {
+	methods map[string]bool // nil means "any method"
+	host    pathMatcher
+	schemes map[string]bool
+	headers [][2]string
+}
+
+// match reports whether hr satisfies p's host/scheme/header predicates.
+// Any parameters captured from the Host header are returned in the same
+// [name, value, ...] shape pathMatcher.match uses, ready to be merged into
+// a route's path parameters.
+func (p *predicates) match(hr *http.Request) (bool, []string) {
+	var params []string
+
+	if p.host != nil {
+		ok, buf := p.host.match(stripPort(hr.Host), nil)
+		if !ok {
+			return false, nil
+		}
+		params = buf
+	}
+
+	if len(p.schemes) > 0 && !p.schemes[requestScheme(hr)] {
+		return false, nil
+	}
+
+	for _, h := range p.headers {
+		v := hr.Header.Get(h[0])
+		if h[1] == "" {
+			if v == "" {
+				return false, nil
+			}
+		} else if v != h[1] {
+			return false, nil
+		}
+	}
+
+	return true, params
+}
+
+// stripPort removes a trailing ":port" from a host header value, leaving
+// IPv6 literals (e.g. "[::1]:8080") intact rather than truncating at the
+// first colon.
+func stripPort(host string) string {
+	if strings.IndexByte(host, ':') < 0 {
+		return host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// requestScheme returns "https" for TLS requests (or ones forwarded as
+// such by a proxy), and "http" otherwise.
+func requestScheme(hr *http.Request) string {
+	if hr.TLS != nil {
+		return "https"
+	}
+	if s := hr.Header.Get("X-Forwarded-Proto"); s != "" {
+		return strings.ToLower(s)
+	}
+	return "http"
+}
+
+// Route lets additional predicates be layered onto a route registered
+// through Mux.Handle.
+type Route struct {
+	pred *predicates
+}
+
+// Handle registers handlers for pattern, initially matching every HTTP
+// method, and returns a Route that lets additional predicates -- Methods,
+// Host, Schemes and Headers -- be layered on afterwards. A path match
+// whose predicates reject the request is treated the same as no match at
+// all (404), not a 405, except for Methods, which still contributes to
+// the Allow header the way Get/Post/... routes do.
+func (m *Mux) Handle(pattern string, handlers ...interface{}) *Route {
+	clean := m.handlerChain(handlers)
+
+	if m.table == nil {
+		m.table = &routeTable{}
+	}
+
+	pred := &predicates{}
+	rt := newRoute("", m.prefix+pattern, clean)
+	rt.pred = pred
+	m.table.add(rt)
+
+	return &Route{pred: pred}
+}
+
+// Methods restricts the route to the given set of HTTP methods, in place
+// of the "any method" default Handle registers it with.
+func (rt *Route) Methods(methods ...string) *Route {
+	rt.pred.methods = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		rt.pred.methods[strings.ToUpper(method)] = true
+	}
+	return rt
+}
+
+// Host restricts the route to requests whose Host header matches pattern.
+// pattern is compiled with the same grammar used for path patterns, so
+// "{sub}.example.com" captures the "sub" parameter, reachable through
+// Request.Param like any other.
+func (rt *Route) Host(pattern string) *Route {
+	matcher, err := compileMatcher(pattern)
+	if err != nil {
+		panic(err)
+	}
+	rt.pred.host = matcher
+	return rt
+}
+
+// Schemes restricts the route to the given URL schemes (e.g. "https").
+func (rt *Route) Schemes(schemes ...string) *Route {
+	if rt.pred.schemes == nil {
+		rt.pred.schemes = make(map[string]bool, len(schemes))
+	}
+	for _, s := range schemes {
+		rt.pred.schemes[strings.ToLower(s)] = true
+	}
+	return rt
+}
+
+// Headers adds a requirement that the request carry a header named key. If
+// value is empty, the header merely has to be present; otherwise its value
+// must match exactly. Headers may be called more than once to require
+// several headers.
+func (rt *Route) Headers(key, value string) *Route {
+	rt.pred.headers = append(rt.pred.headers, [2]string{key, value})
+	return rt
+}
@@ -0,0 +1,121 @@
+package robo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteMethods(t *testing.T) {
+	mux := NewMux()
+	mux.Handle("/ping", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(200)
+	}).Methods("GET", "POST")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("PUT", "http://example.com/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 405 {
+		t.Fatalf("code = %d, want 405", w.Code)
+	}
+
+	want := "GET, HEAD, OPTIONS, POST"
+	if allow := w.Header().Get("Allow"); allow != want {
+		t.Fatalf("Allow = %q, want %q", allow, want)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "http://example.com/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("code = %d, want 200", w.Code)
+	}
+}
+
+func TestRouteHost(t *testing.T) {
+	mux := NewMux()
+	mux.Handle("/ping", func(w ResponseWriter, r *Request) {
+		w.Write([]byte(r.Param("sub")))
+	}).Host("{sub}.example.com")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://api.example.com/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if body := w.Body.String(); body != "api" {
+		t.Fatalf("body = %q, want %q", body, "api")
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "http://other.com/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Fatalf("code = %d, want 404", w.Code)
+	}
+}
+
+func TestRouteHostStripsPortNotIPv6Brackets(t *testing.T) {
+	mux := NewMux()
+	mux.Handle("/ping", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(200)
+	}).Host("::1")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://[::1]:8080/ping", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("code = %d, want 200", w.Code)
+	}
+}
+
+func TestRouteSchemes(t *testing.T) {
+	mux := NewMux()
+	mux.Handle("/secure", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(200)
+	}).Schemes("https")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/secure", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Fatalf("code = %d, want 404", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "http://example.com/secure", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("code = %d, want 200", w.Code)
+	}
+}
+
+func TestRouteHeaders(t *testing.T) {
+	mux := NewMux()
+	mux.Handle("/versioned", func(w ResponseWriter, r *Request) {
+		w.WriteHeader(200)
+	}).Headers("X-Api-Version", "2")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "http://example.com/versioned", nil)
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Fatalf("code = %d, want 404", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "http://example.com/versioned", nil)
+	r.Header.Set("X-Api-Version", "2")
+	mux.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("code = %d, want 200", w.Code)
+	}
+}